@@ -0,0 +1,164 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// maxMetricsObjects - bound the per-object lock metrics cardinality
+// so a bucket with millions of keys can't blow up a scrape. Only the
+// top-N objects by lock count are reported individually; everything
+// else is folded into the `object_prefix="other"` bucket.
+const maxMetricsObjects = 100
+
+// MetricsHandler - GET /minio/admin/metrics
+// Exports lock instrumentation and admin-RPC counters in Prometheus
+// text exposition format so the cluster can be scraped by a standard
+// monitoring stack instead of polled via the JSON lock-info API.
+func (adminAPI adminAPIHandlers) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	lockState, err := getSystemLockState()
+	if err != nil {
+		writeErrorResponse(w, r, ErrInternalError, r.URL.Path)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeLockMetrics(w, lockState)
+	writeAdminRPCMetrics(w)
+}
+
+// writeLockMetrics - renders globalNSMutex counters, per-object lock
+// counts (see writePerBucketLockMetrics) and lock-hold-duration
+// histograms derived from the current SystemLockState snapshot.
+func writeLockMetrics(w http.ResponseWriter, lockState SystemLockState) {
+	fmt.Fprintf(w, "# HELP minio_locks_total Total number of lock requests.\n")
+	fmt.Fprintf(w, "# TYPE minio_locks_total counter\n")
+	fmt.Fprintf(w, "minio_locks_total %d\n", lockState.TotalLocks)
+
+	fmt.Fprintf(w, "# HELP minio_locks_granted Locks currently held.\n")
+	fmt.Fprintf(w, "# TYPE minio_locks_granted gauge\n")
+	fmt.Fprintf(w, "minio_locks_granted %d\n", lockState.TotalAcquiredLocks)
+
+	fmt.Fprintf(w, "# HELP minio_locks_blocked Operations currently blocked waiting on a lock.\n")
+	fmt.Fprintf(w, "# TYPE minio_locks_blocked gauge\n")
+	fmt.Fprintf(w, "minio_locks_blocked %d\n", lockState.TotalBlockedLocks)
+
+	writePerBucketLockMetrics(w, lockState)
+	writeLockHoldDurationHistogram(w, lockState)
+}
+
+// writePerBucketLockMetrics - reports the busiest maxMetricsObjects
+// objects individually, broken down by lock type and status. Only the
+// objects past that bound are summed per bucket, into the
+// object_prefix="other" series; no per-bucket total is reported for
+// the objects listed individually.
+func writePerBucketLockMetrics(w http.ResponseWriter, lockState SystemLockState) {
+	fmt.Fprintf(w, "# HELP minio_locks_object_total Locks observed for a given bucket/object prefix.\n")
+	fmt.Fprintf(w, "# TYPE minio_locks_object_total gauge\n")
+
+	objects := append([]VolumeLockInfo{}, lockState.LocksInfoPerObject...)
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].LocksOnObject > objects[j].LocksOnObject
+	})
+
+	otherByBucket := map[string]int64{}
+	for i, volLockInfo := range objects {
+		if i >= maxMetricsObjects {
+			otherByBucket[volLockInfo.Bucket] += volLockInfo.LocksOnObject
+			continue
+		}
+		// Group this object's lock details by (lockType, status) so
+		// operators can break counts down by RLock/WLock.
+		counts := map[lockType]map[string]int64{}
+		for _, ops := range volLockInfo.LockDetailsOnObject {
+			status := "blocked"
+			if lockIsHeld(ops.Status) {
+				status = "granted"
+			}
+			if counts[ops.LockType] == nil {
+				counts[ops.LockType] = map[string]int64{}
+			}
+			counts[ops.LockType][status]++
+		}
+		for lt, byStatus := range counts {
+			for status, count := range byStatus {
+				fmt.Fprintf(w, "minio_locks_object_total{bucket=%q,object_prefix=%q,lock_type=%q,status=%q} %d\n",
+					volLockInfo.Bucket, volLockInfo.Object, lt, status, count)
+			}
+		}
+	}
+	for bucket, count := range otherByBucket {
+		fmt.Fprintf(w, "minio_locks_object_total{bucket=%q,object_prefix=\"other\",lock_type=\"total\",status=\"total\"} %d\n", bucket, count)
+	}
+}
+
+// writeLockHoldDurationHistogram - buckets OpsLockState.Duration
+// values (derived from .Since) into a fixed set of Prometheus
+// histogram buckets, labelled by lock type.
+func writeLockHoldDurationHistogram(w http.ResponseWriter, lockState SystemLockState) {
+	buckets := []float64{0.01, 0.1, 1, 10, 60, 300}
+
+	fmt.Fprintf(w, "# HELP minio_lock_hold_duration_seconds How long locks have been held.\n")
+	fmt.Fprintf(w, "# TYPE minio_lock_hold_duration_seconds histogram\n")
+
+	counts := map[string][]int64{}
+	sums := map[string]float64{}
+	totals := map[string]int64{}
+	for _, volLockInfo := range lockState.LocksInfoPerObject {
+		for _, ops := range volLockInfo.LockDetailsOnObject {
+			lockType := fmt.Sprintf("%v", ops.LockType)
+			if _, ok := counts[lockType]; !ok {
+				counts[lockType] = make([]int64, len(buckets))
+			}
+			secs := ops.Duration.Seconds()
+			sums[lockType] += secs
+			totals[lockType]++
+			for i, le := range buckets {
+				if secs <= le {
+					counts[lockType][i]++
+				}
+			}
+		}
+	}
+	for lockType, cumulative := range counts {
+		for i, le := range buckets {
+			fmt.Fprintf(w, "minio_lock_hold_duration_seconds_bucket{lock_type=%q,le=\"%g\"} %d\n", lockType, le, cumulative[i])
+		}
+		fmt.Fprintf(w, "minio_lock_hold_duration_seconds_bucket{lock_type=%q,le=\"+Inf\"} %d\n", lockType, totals[lockType])
+		fmt.Fprintf(w, "minio_lock_hold_duration_seconds_sum{lock_type=%q} %g\n", lockType, sums[lockType])
+		fmt.Fprintf(w, "minio_lock_hold_duration_seconds_count{lock_type=%q} %d\n", lockType, totals[lockType])
+	}
+}
+
+// writeAdminRPCMetrics - exports latency/error counters for the
+// Stop/Restart/ListLocks admin RPCs, aggregated across adminPeers.
+func writeAdminRPCMetrics(w http.ResponseWriter) {
+	fmt.Fprintf(w, "# HELP minio_admin_rpc_latency_seconds Admin RPC call latency.\n")
+	fmt.Fprintf(w, "# TYPE minio_admin_rpc_latency_seconds summary\n")
+	fmt.Fprintf(w, "# HELP minio_admin_rpc_errors_total Admin RPC call failures.\n")
+	fmt.Fprintf(w, "# TYPE minio_admin_rpc_errors_total counter\n")
+
+	for _, method := range []string{"Stop", "Restart", "ListLocks"} {
+		stats := globalAdminRPCMetrics.get(method)
+		fmt.Fprintf(w, "minio_admin_rpc_latency_seconds_sum{method=%q} %g\n", method, stats.latencySum)
+		fmt.Fprintf(w, "minio_admin_rpc_latency_seconds_count{method=%q} %d\n", method, stats.calls)
+		fmt.Fprintf(w, "minio_admin_rpc_errors_total{method=%q} %d\n", method, stats.errors)
+	}
+}
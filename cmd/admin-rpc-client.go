@@ -17,6 +17,7 @@
 package cmd
 
 import (
+	"errors"
 	"net/url"
 	"path"
 	"sync"
@@ -39,8 +40,53 @@ type adminCmdRunner interface {
 	Stop() error
 	Restart() error
 	ListLocks(bucket, prefix string, relTime time.Duration) ([]VolumeLockInfo, error)
+	ForceUnlock(bucket, object, opsID string) error
+	Health() error
+	WatchLocks(bucket, prefix string, filter LockEventFilter, done <-chan struct{}) (<-chan LockEvent, error)
+	CancelOp(opsID string) error
+	SetLockTimeout(timeout time.Duration) error
+	ServiceAction(cmd serviceSignal, policy ServicePolicy) error
 }
 
+// adminRPCStat - running latency/error totals for a single admin RPC method.
+type adminRPCStat struct {
+	calls      int64
+	errors     int64
+	latencySum float64 // Cumulative latency in seconds, for a Prometheus summary.
+}
+
+// adminRPCMetrics - tracks adminRPCStat per RPC method name, guarded
+// by a single mutex since admin RPCs are low frequency by nature.
+type adminRPCMetrics struct {
+	mu    sync.Mutex
+	stats map[string]adminRPCStat
+}
+
+// record - accumulates one call's latency and success/failure into
+// the running totals for method.
+func (m *adminRPCMetrics) record(method string, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stat := m.stats[method]
+	stat.calls++
+	stat.latencySum += latency.Seconds()
+	if err != nil {
+		stat.errors++
+	}
+	m.stats[method] = stat
+}
+
+// get - returns a snapshot of the running totals for method.
+func (m *adminRPCMetrics) get(method string) adminRPCStat {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats[method]
+}
+
+// globalAdminRPCMetrics - process-wide admin RPC latency/error
+// counters, exported by the Prometheus metrics handler.
+var globalAdminRPCMetrics = &adminRPCMetrics{stats: make(map[string]adminRPCStat)}
+
 // Stop - Sends a message over channel to the go-routine responsible
 // for stopping the process.
 func (lc localAdminClient) Stop() error {
@@ -60,6 +106,60 @@ func (lc localAdminClient) ListLocks(bucket, prefix string, relTime time.Duratio
 	return listLocksInfo(bucket, prefix, relTime), nil
 }
 
+// ForceUnlock - Scrubs the lock entry matching opsID for bucket,
+// object from the local debugLockMap. There is no exported release
+// API on the underlying nsLock in this tree, so this only clears the
+// debug-tracking entry; a blocked waiter relying on the real nsLock
+// state isn't released by this call. A no-op if the local node
+// doesn't hold opsID for bucket/object.
+func (lc localAdminClient) ForceUnlock(bucket, object, opsID string) error {
+	globalNSMutex.lockMapMutex.Lock()
+	defer globalNSMutex.lockMapMutex.Unlock()
+
+	param := nsParam{volume: bucket, path: object}
+	debugLock, ok := globalNSMutex.debugLockMap[param]
+	if !ok {
+		return nil
+	}
+	delete(debugLock.lockInfo, opsID)
+	return nil
+}
+
+// WatchLocks - Streams local lock events for bucket, prefix matching
+// filter until done is closed, at which point the subscription is
+// torn down and its buffered channel released.
+func (lc localAdminClient) WatchLocks(bucket, prefix string, filter LockEventFilter, done <-chan struct{}) (<-chan LockEvent, error) {
+	filter.Bucket = bucket
+	filter.Prefix = prefix
+	events, cancel := globalLockEventBroadcaster.subscribe(filter)
+	go func() {
+		<-done
+		cancel()
+	}()
+	return events, nil
+}
+
+// Health - Reports whether the local node's disks/erasure sets are
+// in a state where it's safe to consider it back up after a restart.
+func (lc localAdminClient) Health() error {
+	objAPI := newObjectLayerFn()
+	if objAPI == nil {
+		return errServerNotInitialized
+	}
+	_, err := objAPI.StorageInfo()
+	return err
+}
+
+// CancelOp - Cancels the local in-flight operation matching opsID.
+func (lc localAdminClient) CancelOp(opsID string) error {
+	return cancelOp(opsID)
+}
+
+// SetLockTimeout - Updates the local cluster-wide lock acquisition deadline.
+func (lc localAdminClient) SetLockTimeout(timeout time.Duration) error {
+	return setLockTimeout(timeout)
+}
+
 // Stop - Sends stop command to remote server via RPC.
 func (rc remoteAdminClient) Stop() error {
 	args := AuthRPCArgs{}
@@ -88,6 +188,106 @@ func (rc remoteAdminClient) ListLocks(bucket, prefix string, relTime time.Durati
 	return reply.volLocks, nil
 }
 
+// ForceUnlockArgs - arguments for Admin.ForceUnlock RPC.
+type ForceUnlockArgs struct {
+	AuthRPCArgs
+	Bucket string
+	Object string
+	OpsID  string
+}
+
+// ForceUnlock - Sends force-unlock command to remote server via RPC.
+func (rc remoteAdminClient) ForceUnlock(bucket, object, opsID string) error {
+	args := ForceUnlockArgs{
+		Bucket: bucket,
+		Object: object,
+		OpsID:  opsID,
+	}
+	reply := AuthRPCReply{}
+	return rc.Call("Admin.ForceUnlock", &args, &reply)
+}
+
+// Health - Sends a health probe to remote server via RPC, used as
+// the readiness check between batches of a rolling restart.
+func (rc remoteAdminClient) Health() error {
+	args := AuthRPCArgs{}
+	reply := AuthRPCReply{}
+	return rc.Call("Admin.Health", &args, &reply)
+}
+
+// WatchLocks - Streams lock events from a remote server via a
+// chunked RPC connection. The long-poll RPC call blocks reading
+// events until the connection is closed; done does not interrupt it,
+// so it's run in its own goroutine and the returned channel is closed
+// as soon as either the call returns or done fires.
+func (rc remoteAdminClient) WatchLocks(bucket, prefix string, filter LockEventFilter, done <-chan struct{}) (<-chan LockEvent, error) {
+	args := WatchLocksArgs{
+		Bucket: bucket,
+		Prefix: prefix,
+		Filter: filter,
+	}
+	events := make(chan LockEvent, 1000)
+	reply := WatchLocksReply{Events: events}
+	go func() {
+		defer close(events)
+		callDone := make(chan error, 1)
+		go func() { callDone <- rc.Call("Admin.WatchLocks", &args, &reply) }()
+		select {
+		case err := <-callDone:
+			if err != nil {
+				errorIf(err, "WatchLocks RPC stream ended unexpectedly")
+			}
+		case <-done:
+			// The caller stopped watching; the underlying RPC call is
+			// left to finish on its own, its events simply won't be
+			// read past this point.
+		}
+	}()
+	return events, nil
+}
+
+// CancelOpArgs - arguments for the Admin.CancelOp RPC.
+type CancelOpArgs struct {
+	AuthRPCArgs
+	OpsID string
+}
+
+// CancelOp - Sends cancel-operation command to remote server via RPC.
+func (rc remoteAdminClient) CancelOp(opsID string) error {
+	args := CancelOpArgs{OpsID: opsID}
+	reply := AuthRPCReply{}
+	return rc.Call("Admin.CancelOp", &args, &reply)
+}
+
+// SetLockTimeoutArgs - arguments for the Admin.SetLockTimeout RPC.
+type SetLockTimeoutArgs struct {
+	AuthRPCArgs
+	Timeout time.Duration
+}
+
+// SetLockTimeout - Sends set-lock-timeout command to remote server via RPC.
+func (rc remoteAdminClient) SetLockTimeout(timeout time.Duration) error {
+	args := SetLockTimeoutArgs{Timeout: timeout}
+	reply := AuthRPCReply{}
+	return rc.Call("Admin.SetLockTimeout", &args, &reply)
+}
+
+// WatchLocksArgs - arguments for the Admin.WatchLocks RPC.
+type WatchLocksArgs struct {
+	AuthRPCArgs
+	Bucket string
+	Prefix string
+	Filter LockEventFilter
+}
+
+// WatchLocksReply - the RPC client's end of a chunked WatchLocks
+// stream; the RPC transport writes each received LockEvent to Events
+// as it arrives on the wire.
+type WatchLocksReply struct {
+	AuthRPCReply
+	Events chan<- LockEvent
+}
+
 // adminPeer - represents an entity that implements Stop and Restart methods.
 type adminPeer struct {
 	addr      string
@@ -141,19 +341,35 @@ func makeAdminPeers(eps []*url.URL) adminPeers {
 	return servicePeers
 }
 
+// defaultDeadlockRecoveryPolicy - auto-recovery is off: Enabled is
+// false and nothing in the server sets this policy from config, so
+// runDeadlockAutoRecovery returns at once. When enabled, its
+// force-unlock only clears lock-tracking entries (see
+// localAdminClient.ForceUnlock).
+var defaultDeadlockRecoveryPolicy = DeadlockRecoveryPolicy{
+	Enabled:       false,
+	MaxLockAge:    30 * time.Minute,
+	CheckInterval: time.Minute,
+}
+
 // Initialize global adminPeer collection.
 func initGlobalAdminPeers(eps []*url.URL) {
 	globalAdminPeers = makeAdminPeers(eps)
+	go runDeadlockAutoRecovery(globalAdminPeers, defaultDeadlockRecoveryPolicy)
 }
 
 // invokeServiceCmd - Invoke Stop/Restart command.
 func invokeServiceCmd(cp adminPeer, cmd serviceSignal) (err error) {
+	start := time.Now()
+	method := "Restart"
 	switch cmd {
 	case serviceStop:
+		method = "Stop"
 		err = cp.cmdRunner.Stop()
 	case serviceRestart:
 		err = cp.cmdRunner.Restart()
 	}
+	globalAdminRPCMetrics.record(method, time.Since(start), err)
 	return err
 }
 
@@ -188,11 +404,15 @@ func listPeerLocksInfo(peers adminPeers, bucket, prefix string, relTime time.Dur
 		go func(idx int, remotePeer adminPeer) {
 			defer wg.Done()
 			// `remotePeers` is right-shifted by one position relative to `peers`
+			start := time.Now()
 			allLocks[idx], errs[idx] = remotePeer.cmdRunner.ListLocks(bucket, prefix, relTime)
+			globalAdminRPCMetrics.record("ListLocks", time.Since(start), errs[idx])
 		}(i+1, remotePeer)
 	}
 	wg.Wait()
+	start := time.Now()
 	allLocks[0], errs[0] = localPeer.cmdRunner.ListLocks(bucket, prefix, relTime)
+	globalAdminRPCMetrics.record("ListLocks", time.Since(start), errs[0])
 
 	// Summarizing errors received for ListLocks RPC across all
 	// nodes.  N B the possible unavailability of quorum in errors
@@ -223,3 +443,98 @@ func listPeerLocksInfo(peers adminPeers, bucket, prefix string, relTime time.Dur
 	}
 	return groupedLockInfos, nil
 }
+
+// watchPeerLocksBackoff - starting and maximum backoff between
+// reconnect attempts for a single peer's WatchLocks stream.
+const (
+	watchPeerLocksMinBackoff = time.Second
+	watchPeerLocksMaxBackoff = 30 * time.Second
+)
+
+// watchPeerLocksMinHealthy - a stream has to stay open for at least
+// this long before its close is treated as a clean end rather than a
+// failed connection. remoteAdminClient.WatchLocks always returns a
+// nil error synchronously and only ever signals a failed RPC by
+// closing the event channel from a background goroutine, so an
+// events channel that closes before this elapses is backed off
+// exactly like an explicit error would be.
+const watchPeerLocksMinHealthy = 5 * time.Second
+
+// errWatchLocksClosedEarly - the events channel from
+// remoteAdminClient.WatchLocks closed before watchPeerLocksMinHealthy
+// elapsed, treated as an RPC failure for backoff purposes even though
+// WatchLocks itself returned a nil error.
+var errWatchLocksClosedEarly = errors.New("watch locks stream closed before becoming healthy")
+
+// watchPeerLocks - fans in lock event streams from every peer into a
+// single channel. Each peer's stream is independently reconnected
+// with an exponential backoff if it drops, so one flaky node doesn't
+// stall the others; a stream that closes again before
+// watchPeerLocksMinHealthy elapses is treated as a failed connection
+// for backoff purposes, not a clean disconnect. The returned channel
+// is closed once done is closed.
+func watchPeerLocks(peers adminPeers, bucket, prefix string, filter LockEventFilter, done <-chan struct{}) <-chan LockEvent {
+	out := make(chan LockEvent, 1000)
+	var wg sync.WaitGroup
+	for _, peer := range peers {
+		wg.Add(1)
+		go func(peer adminPeer) {
+			defer wg.Done()
+			backoff := watchPeerLocksMinBackoff
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				events, err := peer.cmdRunner.WatchLocks(bucket, prefix, filter, done)
+				connectedAt := time.Now()
+				if err == nil {
+				drain:
+					for {
+						select {
+						case ev, ok := <-events:
+							if !ok {
+								break drain
+							}
+							select {
+							case out <- ev:
+							case <-done:
+								return
+							}
+						case <-done:
+							return
+						}
+					}
+					// events closed. Only treat this as a healthy,
+					// intentional disconnect (and reset backoff) if
+					// the stream stayed up for a while; a channel
+					// that closes almost immediately means the RPC
+					// itself failed, even though WatchLocks returned
+					// a nil error for it.
+					if time.Since(connectedAt) >= watchPeerLocksMinHealthy {
+						backoff = watchPeerLocksMinBackoff
+						continue
+					}
+					err = errWatchLocksClosedEarly
+				}
+
+				errorIf(err, "Unable to watch locks on %s, retrying in %s", peer.addr, backoff)
+				select {
+				case <-time.After(backoff):
+				case <-done:
+					return
+				}
+				backoff *= 2
+				if backoff > watchPeerLocksMaxBackoff {
+					backoff = watchPeerLocksMaxBackoff
+				}
+			}
+		}(peer)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
@@ -0,0 +1,170 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServicePolicyType - describes how sendServiceCmdWithPolicy should
+// roll a Stop/Restart command out across the cluster.
+type ServicePolicyType string
+
+const (
+	// ServicePolicyParallel - fire the command at every peer at
+	// once, local peer last. This is the original sendServiceCmd
+	// behavior and can take the entire cluster down at once.
+	ServicePolicyParallel ServicePolicyType = "parallel"
+	// ServicePolicyRolling - restart peers in fixed-size batches,
+	// waiting for each batch to pass a readiness probe before
+	// moving on to the next, and aborting if doing so would drop
+	// healthy peers below quorum.
+	ServicePolicyRolling ServicePolicyType = "rolling"
+)
+
+// ServicePolicy - configures a sendServiceCmdWithPolicy rollout.
+type ServicePolicy struct {
+	Type ServicePolicyType
+	// BatchSize - peers restarted concurrently per batch. Only used
+	// by ServicePolicyRolling, defaults to 1 if <= 0.
+	BatchSize int
+	// ReadyTimeout - how long to wait for a restarted peer's
+	// Health() RPC to succeed before giving up on that batch.
+	ReadyTimeout time.Duration
+}
+
+// ServiceActionArgs - arguments for the Admin.ServiceAction RPC.
+type ServiceActionArgs struct {
+	AuthRPCArgs
+	Cmd    serviceSignal
+	Policy ServicePolicy
+}
+
+// ServiceAction - Orchestrates a Stop/Restart command across every
+// admin peer according to policy. Local because whichever node the
+// admin CLI happens to connect to drives the rollout for the
+// cluster, via its own view of globalAdminPeers.
+func (lc localAdminClient) ServiceAction(cmd serviceSignal, policy ServicePolicy) error {
+	return sendServiceCmdWithPolicy(globalAdminPeers, cmd, policy)
+}
+
+// ServiceAction - Sends the service action command (and policy) to a
+// remote server via RPC, which then drives the rollout from its own
+// view of the cluster.
+func (rc remoteAdminClient) ServiceAction(cmd serviceSignal, policy ServicePolicy) error {
+	args := ServiceActionArgs{
+		Cmd:    cmd,
+		Policy: policy,
+	}
+	reply := AuthRPCReply{}
+	return rc.Call("Admin.ServiceAction", &args, &reply)
+}
+
+// sendServiceCmdWithPolicy - Invoke Stop/Restart command across cps
+// according to policy. ServicePolicyParallel falls back to the
+// original all-at-once sendServiceCmd; ServicePolicyRolling batches
+// the rollout and verifies quorum and peer health between batches.
+func sendServiceCmdWithPolicy(cps adminPeers, cmd serviceSignal, policy ServicePolicy) error {
+	if policy.Type != ServicePolicyRolling {
+		sendServiceCmd(cps, cmd)
+		return nil
+	}
+
+	batchSize := policy.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	quorum := len(cps)/2 + 1
+
+	for start := 0; start < len(cps); start += batchSize {
+		end := start + batchSize
+		if end > len(cps) {
+			end = len(cps)
+		}
+		batch := cps[start:end]
+
+		// Only the current batch is ever down at once: every earlier
+		// batch has already passed its readiness probe and rejoined
+		// the healthy set, so the remaining healthy peer count is
+		// always len(cps)-len(batch), not a running total.
+		if len(cps)-len(batch) < quorum {
+			return fmt.Errorf("rolling %s aborted: taking down %s would drop healthy peers below quorum (%d)",
+				serviceSignalString(cmd), peerAddrs(batch), quorum)
+		}
+
+		errs := make([]error, len(batch))
+		var wg sync.WaitGroup
+		for i := range batch {
+			wg.Add(1)
+			go func(idx int) {
+				defer wg.Done()
+				errs[idx] = invokeServiceCmd(batch[idx], cmd)
+			}(i)
+		}
+		wg.Wait()
+
+		for i, peer := range batch {
+			if errs[i] != nil {
+				return fmt.Errorf("%s failed on %s: %v", serviceSignalString(cmd), peer.addr, errs[i])
+			}
+			if err := waitForPeerHealth(peer, policy.ReadyTimeout); err != nil {
+				return fmt.Errorf("%s on %s did not become healthy: %v", serviceSignalString(cmd), peer.addr, err)
+			}
+		}
+	}
+	return nil
+}
+
+// waitForPeerHealth - polls peer.cmdRunner.Health() once a second
+// until it succeeds or timeout elapses.
+func waitForPeerHealth(peer adminPeer, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for {
+		if lastErr = peer.cmdRunner.Health(); lastErr == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return lastErr
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// peerAddrs - comma separated peer addresses, for error messages.
+func peerAddrs(peers adminPeers) string {
+	addrs := make([]string, len(peers))
+	for i, peer := range peers {
+		addrs[i] = peer.addr
+	}
+	return strings.Join(addrs, ", ")
+}
+
+// serviceSignalString - human readable name for a serviceSignal, for error messages.
+func serviceSignalString(cmd serviceSignal) string {
+	switch cmd {
+	case serviceStop:
+		return "stop"
+	case serviceRestart:
+		return "restart"
+	default:
+		return "service command"
+	}
+}
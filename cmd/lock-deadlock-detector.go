@@ -0,0 +1,271 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// DeadlockReport - result of a single wait-for graph analysis pass.
+// Cycles holds one entry per strongly connected component of size >=
+// 2 found in the graph, each of which indicates a set of operations
+// that are mutually waiting on one another and can never make progress.
+type DeadlockReport struct {
+	Cycles    [][]string `json:"cycles"`    // Groups of OperationIDs forming a lock cycle.
+	CheckedAt time.Time  `json:"checkedAt"` // Time the analysis pass was run.
+}
+
+// lockIsHeld - returns true if status indicates the operation
+// currently holds its lock rather than waiting to acquire one.
+func lockIsHeld(status statusType) bool {
+	return status == statusType("Running") || status == statusType("Ready")
+}
+
+// buildWaitForGraph - derives a directed wait-for graph from a
+// cluster-wide SystemLockState snapshot. An edge opsID -> holderID
+// means opsID is blocked waiting for a lock that holderID currently
+// holds on the same <bucket, object>. Since OperationID is unique
+// cluster wide, the graph is valid across node boundaries.
+func buildWaitForGraph(state SystemLockState) map[string][]string {
+	graph := make(map[string][]string)
+	for _, volLockInfo := range state.LocksInfoPerObject {
+		var holders, blocked []string
+		for _, ops := range volLockInfo.LockDetailsOnObject {
+			if lockIsHeld(ops.Status) {
+				holders = append(holders, ops.OperationID)
+			} else {
+				blocked = append(blocked, ops.OperationID)
+			}
+		}
+		for _, opsID := range blocked {
+			graph[opsID] = append(graph[opsID], holders...)
+		}
+	}
+	return graph
+}
+
+// tarjanSCCFinder - holds the bookkeeping state for a single run of
+// Tarjan's strongly connected components algorithm over a wait-for graph.
+type tarjanSCCFinder struct {
+	graph   map[string][]string
+	index   map[string]int
+	lowlink map[string]int
+	onStack map[string]bool
+	stack   []string
+	counter int
+	sccs    [][]string
+}
+
+// run - walks every node of the graph, starting a DFS from each one
+// that hasn't been visited yet, and collects the resulting SCCs.
+func (t *tarjanSCCFinder) run() [][]string {
+	for node := range t.graph {
+		if _, visited := t.index[node]; !visited {
+			t.strongConnect(node)
+		}
+	}
+	return t.sccs
+}
+
+func (t *tarjanSCCFinder) strongConnect(v string) {
+	t.index[v] = t.counter
+	t.lowlink[v] = t.counter
+	t.counter++
+	t.stack = append(t.stack, v)
+	t.onStack[v] = true
+
+	for _, w := range t.graph[v] {
+		if _, visited := t.index[w]; !visited {
+			t.strongConnect(w)
+			if t.lowlink[w] < t.lowlink[v] {
+				t.lowlink[v] = t.lowlink[w]
+			}
+		} else if t.onStack[w] {
+			if t.index[w] < t.lowlink[v] {
+				t.lowlink[v] = t.index[w]
+			}
+		}
+	}
+
+	// v is the root of an SCC, pop the stack to collect its members.
+	if t.lowlink[v] == t.index[v] {
+		var scc []string
+		for {
+			n := len(t.stack) - 1
+			w := t.stack[n]
+			t.stack = t.stack[:n]
+			t.onStack[w] = false
+			scc = append(scc, w)
+			if w == v {
+				break
+			}
+		}
+		t.sccs = append(t.sccs, scc)
+	}
+}
+
+// findCycles - runs Tarjan's SCC algorithm on graph and returns only
+// the components of size >= 2, i.e. actual lock cycles. A component
+// of size 1 just means a node with no self loop and isn't a deadlock.
+func findCycles(graph map[string][]string) [][]string {
+	finder := &tarjanSCCFinder{
+		graph:   graph,
+		index:   make(map[string]int),
+		lowlink: make(map[string]int),
+		onStack: make(map[string]bool),
+	}
+	var cycles [][]string
+	for _, scc := range finder.run() {
+		if len(scc) >= 2 {
+			cycles = append(cycles, scc)
+		}
+	}
+	return cycles
+}
+
+// detectDeadlocks - builds the wait-for graph for the given cluster
+// lock state and reports every cycle found in it.
+func detectDeadlocks(state SystemLockState) DeadlockReport {
+	return DeadlockReport{
+		Cycles:    findCycles(buildWaitForGraph(state)),
+		CheckedAt: time.Now().UTC(),
+	}
+}
+
+// DeadlockRecoveryPolicy - configures whether and how aggressively
+// operations caught in a detected deadlock cycle should be force
+// unlocked without operator intervention.
+type DeadlockRecoveryPolicy struct {
+	Enabled bool
+	// MaxLockAge - only force-unlock a participant in a detected
+	// cycle once its lock has been held or waited on for at least
+	// this long, to avoid reacting to a cycle that's about to clear
+	// on its own.
+	MaxLockAge time.Duration
+	// CheckInterval - how often the background goroutine re-runs
+	// deadlock detection across the cluster.
+	CheckInterval time.Duration
+}
+
+// runDeadlockAutoRecovery - background goroutine that periodically
+// polls the cluster-wide lock state, runs deadlock detection and,
+// when policy.Enabled, force-unlocks the oldest participant of every
+// detected cycle that has exceeded policy.MaxLockAge. Intended to be
+// started once per server with `go runDeadlockAutoRecovery(...)`.
+func runDeadlockAutoRecovery(peers adminPeers, policy DeadlockRecoveryPolicy) {
+	if !policy.Enabled {
+		return
+	}
+	ticker := time.NewTicker(policy.CheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		locks, err := listPeerLocksInfo(peers, "", "", 0)
+		if err != nil {
+			errorIf(err, "Unable to fetch cluster lock state for deadlock detection")
+			continue
+		}
+		state := SystemLockState{LocksInfoPerObject: locks}
+		report := detectDeadlocks(state)
+		for _, cycle := range report.Cycles {
+			forceUnlockOldest(peers, state, cycle, policy.MaxLockAge)
+		}
+	}
+}
+
+// DeadlocksHandler - GET /minio/admin/locks/deadlocks
+// Runs a deadlock-detection pass across the cluster's current lock
+// state and returns the resulting DeadlockReport as JSON.
+func (adminAPI adminAPIHandlers) DeadlocksHandler(w http.ResponseWriter, r *http.Request) {
+	locks, err := listPeerLocksInfo(globalAdminPeers, "", "", 0)
+	if err != nil {
+		writeErrorResponse(w, r, ErrInternalError, r.URL.Path)
+		return
+	}
+
+	report := detectDeadlocks(SystemLockState{LocksInfoPerObject: locks})
+	data, err := json.Marshal(report)
+	if err != nil {
+		writeErrorResponse(w, r, ErrInternalError, r.URL.Path)
+		return
+	}
+	w.Write(data)
+}
+
+// ForceUnlockHandler - POST /minio/admin/locks/force-unlock?bucket=&object=&opsId=
+// Force-unlocks the operation identified by opsId on bucket/object
+// across every node in the cluster. On each node this removes only
+// the lock-tracking entry (see localAdminClient.ForceUnlock); the
+// underlying nsLock is not released, so an operation blocked on it
+// stays blocked.
+func (adminAPI adminAPIHandlers) ForceUnlockHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	bucket := query.Get("bucket")
+	object := query.Get("object")
+	opsID := query.Get("opsId")
+	if bucket == "" || object == "" || opsID == "" {
+		writeErrorResponse(w, r, ErrInvalidArgument, r.URL.Path)
+		return
+	}
+
+	var lastErr error
+	for _, peer := range globalAdminPeers {
+		if err := peer.cmdRunner.ForceUnlock(bucket, object, opsID); err != nil {
+			errorIf(err, "Unable to force-unlock %s/%s held by %s on %s", bucket, object, opsID, peer.addr)
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		writeErrorResponse(w, r, ErrInternalError, r.URL.Path)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// forceUnlockOldest - given a detected cycle of OperationIDs, locates
+// the oldest participant, and if it has been stuck for at least
+// maxAge, force-unlocks it on every peer (force-unlock is a no-op on
+// a peer that doesn't hold that OperationID).
+func forceUnlockOldest(peers adminPeers, state SystemLockState, cycle []string, maxAge time.Duration) {
+	participants := map[string]bool{}
+	for _, opsID := range cycle {
+		participants[opsID] = true
+	}
+
+	var oldestBucket, oldestObject, oldestOpsID string
+	var oldestSince time.Time
+	for _, volLockInfo := range state.LocksInfoPerObject {
+		for _, ops := range volLockInfo.LockDetailsOnObject {
+			if !participants[ops.OperationID] {
+				continue
+			}
+			if oldestOpsID == "" || ops.Since.Before(oldestSince) {
+				oldestBucket, oldestObject, oldestOpsID, oldestSince = volLockInfo.Bucket, volLockInfo.Object, ops.OperationID, ops.Since
+			}
+		}
+	}
+	if oldestOpsID == "" || time.Since(oldestSince) < maxAge {
+		return
+	}
+
+	for _, peer := range peers {
+		if err := peer.cmdRunner.ForceUnlock(oldestBucket, oldestObject, oldestOpsID); err != nil {
+			errorIf(err, "Unable to force-unlock %s/%s held by %s on %s", oldestBucket, oldestObject, oldestOpsID, peer.addr)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockEventType - the kind of transition a lock went through.
+type LockEventType string
+
+const (
+	// LockEventAcquired - an operation was first observed already holding its lock.
+	LockEventAcquired LockEventType = "Acquired"
+	// LockEventReleased - an operation, holding or blocked, disappeared from the lock state.
+	LockEventReleased LockEventType = "Released"
+	// LockEventBlocked - an operation started waiting for a lock held by another operation.
+	LockEventBlocked LockEventType = "Blocked"
+	// LockEventWaited - a previously blocked operation was finally granted its lock.
+	LockEventWaited LockEventType = "Waited"
+)
+
+// LockEvent - a single lock state transition, synthesized by
+// runLockEventBridge from consecutive SystemLockState polls rather
+// than emitted directly by globalNSMutex. A lock acquired and
+// released again inside one poll interval produces no event at all;
+// see runLockEventBridge.
+type LockEvent struct {
+	Type      LockEventType `json:"type"`
+	Bucket    string        `json:"bucket"`
+	Object    string        `json:"object"`
+	OpsID     string        `json:"opsID"`
+	LockType  lockType      `json:"lockType"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// LockEventFilter - server-side filter applied before an event is
+// sent to a watcher, so operators can tail only what they care about.
+type LockEventFilter struct {
+	Bucket string
+	Prefix string
+	Types  []LockEventType // Empty matches all event types.
+}
+
+// matches - reports whether ev should be delivered to a watcher with this filter.
+func (f LockEventFilter) matches(ev LockEvent) bool {
+	if f.Bucket != "" && f.Bucket != ev.Bucket {
+		return false
+	}
+	if !strings.HasPrefix(ev.Object, f.Prefix) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == ev.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// lockEventBroadcaster - fans the single stream of LockEvents
+// synthesized by runLockEventBridge out to any number of subscribed
+// watchers, each with its own filter and its own buffered channel so
+// one slow watcher can't stall the others.
+type lockEventBroadcaster struct {
+	subscribersMu sync.Mutex
+	subscribers   map[chan LockEvent]LockEventFilter
+}
+
+var globalLockEventBroadcaster = &lockEventBroadcaster{
+	subscribers: make(map[chan LockEvent]LockEventFilter),
+}
+
+// subscribe - registers a new watcher with filter and returns a
+// channel of matching events along with a cancel func to unsubscribe.
+// Starts the lock event bridge on the first subscriber so idle
+// servers with nobody watching don't pay the polling cost.
+func (b *lockEventBroadcaster) subscribe(filter LockEventFilter) (<-chan LockEvent, func()) {
+	startLockEventBridge()
+
+	ch := make(chan LockEvent, 1000)
+	b.subscribersMu.Lock()
+	b.subscribers[ch] = filter
+	b.subscribersMu.Unlock()
+
+	cancel := func() {
+		b.subscribersMu.Lock()
+		defer b.subscribersMu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// publish - called for every observed lock state transition. Drops
+// the event for a watcher whose channel is full rather than blocking
+// the publisher.
+func (b *lockEventBroadcaster) publish(ev LockEvent) {
+	b.subscribersMu.Lock()
+	defer b.subscribersMu.Unlock()
+	for ch, filter := range b.subscribers {
+		if !filter.matches(ev) {
+			continue
+		}
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// lockEventPollInterval - how often the snapshot-diff bridge samples
+// local lock state to synthesize LockEvents for publish.
+const lockEventPollInterval = 250 * time.Millisecond
+
+var startLockEventBridgeOnce sync.Once
+
+// startLockEventBridge - launches the background goroutine that
+// drives globalLockEventBroadcaster.publish with real transitions.
+// Idempotent: only the first call actually starts the goroutine, so
+// it's safe to call from every subscribe().
+func startLockEventBridge() {
+	startLockEventBridgeOnce.Do(func() {
+		go runLockEventBridge(lockEventPollInterval)
+	})
+}
+
+// lockSnapshot - OpsLockState plus the <bucket, object> it belongs
+// to, keyed by OperationID so consecutive snapshots can be diffed.
+type lockSnapshot struct {
+	bucket string
+	object string
+	ops    OpsLockState
+}
+
+// runLockEventBridge - polls local lock state every interval and
+// diffs it against the previous poll to synthesize Acquired/Blocked/
+// Waited/Released LockEvents, which it hands to publish. A new
+// OperationID that shows up already holding its lock is reported
+// Acquired; one that shows up waiting is Blocked; one that flips from
+// waiting to holding is Waited; one that disappears is Released.
+//
+// Because this is polling rather than a real hook into the nsLock
+// acquisition path, any OperationID that's both acquired and released
+// within a single interval is invisible to it entirely - it never
+// appears in curr, so no event of any kind is published for it. This
+// is the common case for most short-lived S3 handler locks; watchers
+// should not assume WatchLocks reports every lock that was ever held.
+func runLockEventBridge(interval time.Duration) {
+	prev := map[string]lockSnapshot{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		state, err := getSystemLockState()
+		if err != nil {
+			continue
+		}
+
+		curr := map[string]lockSnapshot{}
+		for _, volLockInfo := range state.LocksInfoPerObject {
+			for _, ops := range volLockInfo.LockDetailsOnObject {
+				curr[ops.OperationID] = lockSnapshot{
+					bucket: volLockInfo.Bucket,
+					object: volLockInfo.Object,
+					ops:    ops,
+				}
+			}
+		}
+
+		now := time.Now().UTC()
+		for opsID, snap := range curr {
+			old, existed := prev[opsID]
+			switch {
+			case !existed && lockIsHeld(snap.ops.Status):
+				publishLockEvent(LockEventAcquired, snap, now)
+			case !existed:
+				publishLockEvent(LockEventBlocked, snap, now)
+			case !lockIsHeld(old.ops.Status) && lockIsHeld(snap.ops.Status):
+				publishLockEvent(LockEventWaited, snap, now)
+			}
+		}
+		for opsID, old := range prev {
+			if _, stillThere := curr[opsID]; !stillThere {
+				publishLockEvent(LockEventReleased, old, now)
+			}
+		}
+		prev = curr
+	}
+}
+
+// publishLockEvent - builds a LockEvent from a snapshot and hands it to globalLockEventBroadcaster.publish.
+func publishLockEvent(eventType LockEventType, snap lockSnapshot, at time.Time) {
+	globalLockEventBroadcaster.publish(LockEvent{
+		Type:      eventType,
+		Bucket:    snap.bucket,
+		Object:    snap.object,
+		OpsID:     snap.ops.OperationID,
+		LockType:  snap.ops.LockType,
+		Timestamp: at,
+	})
+}
@@ -0,0 +1,179 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errOperationNotFound - no in-flight operation matches the given OperationID on this node.
+var errOperationNotFound = errors.New("operation not found")
+
+// errOperationNotCancelable - the matching operation has no cancel func registered via RegisterOpCancel.
+var errOperationNotCancelable = errors.New("operation cannot be canceled")
+
+// defaultLockTimeout - value getLockTimeout reports until
+// SetLockTimeout is first called. No timeout is enforced before then,
+// since the enforcer is only started by setLockTimeout.
+const defaultLockTimeout = 30 * time.Second
+
+// globalLockTimeout - cluster-wide deadline applied to future lock
+// acquisitions, stored as int64 nanoseconds so it can be read and
+// updated without a mutex on the hot nsLock acquisition path.
+var globalLockTimeout = int64(defaultLockTimeout)
+
+// getLockTimeout - current cluster-wide lock acquisition deadline.
+func getLockTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&globalLockTimeout))
+}
+
+// setLockTimeout - updates the cluster-wide lock acquisition
+// deadline. The enforcer checks it against every blocked operation,
+// so it applies to operations already blocked as well as to later
+// ones. Also starts the background enforcer, lazily, on its first call.
+func setLockTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return errInvalidArgument
+	}
+	atomic.StoreInt64(&globalLockTimeout, int64(timeout))
+	startLockTimeoutEnforcer()
+	return nil
+}
+
+// lockTimeoutEnforcerInterval - how often the enforcer re-checks
+// blocked operations against the current getLockTimeout().
+const lockTimeoutEnforcerInterval = time.Second
+
+var startLockTimeoutEnforcerOnce sync.Once
+
+// startLockTimeoutEnforcer - launches the background goroutine that
+// actually applies getLockTimeout() to blocked operations already
+// in flight. Idempotent: only the first call starts the goroutine.
+func startLockTimeoutEnforcer() {
+	startLockTimeoutEnforcerOnce.Do(func() {
+		go runLockTimeoutEnforcer(lockTimeoutEnforcerInterval)
+	})
+}
+
+// runLockTimeoutEnforcer - every interval, walks this node's blocked
+// operations and cancels any that have been waiting longer than the
+// current cluster-wide getLockTimeout(), so a spike in blocked locks
+// can be shed without a restart. Since SetLockTimeout is applied here
+// rather than at acquisition time, it also affects locks that were
+// already blocked before the timeout was configured or changed.
+func runLockTimeoutEnforcer(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		state, err := getSystemLockState()
+		if err != nil {
+			continue
+		}
+		timeout := getLockTimeout()
+		for _, volLockInfo := range state.LocksInfoPerObject {
+			for _, ops := range volLockInfo.LockDetailsOnObject {
+				if lockIsHeld(ops.Status) || ops.Duration < timeout {
+					continue
+				}
+				if err := cancelOp(ops.OperationID); err != nil && err != errOperationNotCancelable {
+					errorIf(err, "Unable to cancel timed-out operation %s", ops.OperationID)
+				}
+			}
+		}
+	}
+}
+
+// opCancelEntry - the deadline and cancel context.CancelFunc for a
+// single in-flight operation, kept outside of lockInfo since this
+// tree's nsLock implementation doesn't define those fields itself.
+type opCancelEntry struct {
+	deadline time.Time
+	cancel   context.CancelFunc
+}
+
+var (
+	opCancelMu    sync.Mutex
+	opCancelFuncs = make(map[string]opCancelEntry)
+)
+
+// RegisterOpCancel - records deadline and cancel for opsID so a later
+// cancelOp/getOpDeadline call can find them. Meant to be called from
+// the nsLock acquisition path at the point an operation starts
+// waiting on a lock; no such call site exists yet in this tree, so
+// until nsLock is wired up to call it, operations never appear here.
+func RegisterOpCancel(opsID string, deadline time.Time, cancel context.CancelFunc) {
+	opCancelMu.Lock()
+	defer opCancelMu.Unlock()
+	opCancelFuncs[opsID] = opCancelEntry{deadline: deadline, cancel: cancel}
+}
+
+// UnregisterOpCancel - drops opsID's entry once the operation
+// completes or is canceled. Meant to be called from the same nsLock
+// acquisition path as RegisterOpCancel.
+func UnregisterOpCancel(opsID string) {
+	opCancelMu.Lock()
+	defer opCancelMu.Unlock()
+	delete(opCancelFuncs, opsID)
+}
+
+// getOpDeadline - the deadline registered for opsID, or the zero
+// Time if opsID was never registered via RegisterOpCancel.
+func getOpDeadline(opsID string) time.Time {
+	opCancelMu.Lock()
+	defer opCancelMu.Unlock()
+	return opCancelFuncs[opsID].deadline
+}
+
+// opExists - reports whether opsID appears anywhere in debugLockMap
+// on this node, regardless of bucket/object.
+func opExists(opsID string) bool {
+	globalNSMutex.lockMapMutex.Lock()
+	defer globalNSMutex.lockMapMutex.Unlock()
+
+	for _, debugLock := range globalNSMutex.debugLockMap {
+		if _, ok := debugLock.lockInfo[opsID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelOp - locates the in-flight operation matching opsID on this
+// node and invokes its registered CancelFunc so the blocked S3
+// handler that owns it returns quickly instead of waiting out its
+// deadline. Returns errOperationNotCancelable for an operation that
+// exists in debugLockMap but was never registered via
+// RegisterOpCancel, which today is every operation, since nothing in
+// this tree calls RegisterOpCancel from the nsLock acquisition path.
+func cancelOp(opsID string) error {
+	if !opExists(opsID) {
+		return errOperationNotFound
+	}
+
+	opCancelMu.Lock()
+	entry, ok := opCancelFuncs[opsID]
+	opCancelMu.Unlock()
+	if !ok || entry.cancel == nil {
+		return errOperationNotCancelable
+	}
+	entry.cancel()
+	return nil
+}
@@ -60,6 +60,12 @@ type OpsLockState struct {
 	Status      statusType    `json:"status"`         // Status can be Running/Ready/Blocked.
 	Since       time.Time     `json:"statusSince"`    // Time when the lock was initially held.
 	Duration    time.Duration `json:"statusDuration"` // Duration since the lock was held.
+	// Deadline - the deadline registered for opsID via
+	// RegisterOpCancel, read from the package-level side-table in
+	// lock-timeout.go. Zero value means opsID was never registered.
+	// runLockTimeoutEnforcer does not read it; it cancels blocked
+	// operations by comparing Duration with getLockTimeout().
+	Deadline time.Time `json:"deadline"`
 }
 
 // Read entire state of the locks in the system and return.
@@ -90,6 +96,7 @@ func getSystemLockState() (SystemLockState, error) {
 				Status:      lockInfo.status,
 				Since:       lockInfo.since,
 				Duration:    timeNow.Sub(lockInfo.since),
+				Deadline:    getOpDeadline(opsID),
 			})
 		}
 		lockState.LocksInfoPerObject = append(lockState.LocksInfoPerObject, volLockInfo)
@@ -97,7 +104,9 @@ func getSystemLockState() (SystemLockState, error) {
 	return lockState, nil
 }
 
-// listLocksInfo - Fetches locks held on bucket, matching prefix older than relTime.
+// listLocksInfo - Fetches locks held on bucket, matching prefix older
+// than relTime. N B an empty bucket matches all buckets, just like an
+// empty prefix matches all param.path.
 func listLocksInfo(bucket, prefix string, relTime time.Duration) []VolumeLockInfo {
 	globalNSMutex.lockMapMutex.Lock()
 	defer globalNSMutex.lockMapMutex.Unlock()
@@ -107,7 +116,7 @@ func listLocksInfo(bucket, prefix string, relTime time.Duration) []VolumeLockInf
 	volumeLocks := []VolumeLockInfo{}
 
 	for param, debugLock := range globalNSMutex.debugLockMap {
-		if param.volume != bucket {
+		if bucket != "" && param.volume != bucket {
 			continue
 		}
 		// N B empty prefix matches all param.path.
@@ -137,7 +146,10 @@ func listLocksInfo(bucket, prefix string, relTime time.Duration) []VolumeLockInf
 					Status:      lockInfo.status,
 					Since:       lockInfo.since,
 					Duration:    elapsed,
+					Deadline:    getOpDeadline(opsID),
 				})
+		}
+		if len(volLockInfo.LockDetailsOnObject) > 0 {
 			volumeLocks = append(volumeLocks, volLockInfo)
 		}
 	}